@@ -0,0 +1,289 @@
+package main
+
+import (
+    "bufio"
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "net"
+    "net/http"
+    "os"
+    "strings"
+    "sync"
+    "time"
+
+    "google.golang.org/grpc"
+    "google.golang.org/grpc/credentials/insecure"
+    "google.golang.org/grpc/encoding"
+)
+
+// Forwarder sends an RPC's method and params to an upstream and returns its
+// raw JSON payload. The bridge selects an implementation per method via the
+// routing table below, so different RPCs can be served by different
+// backends instead of a single hardcoded reverse proxy.
+type Forwarder interface {
+    Forward(ctx context.Context, method string, params json.RawMessage) (json.RawMessage, error)
+}
+
+// requestEnvelope is the wire shape every transport sends upstream: the RPC
+// method name alongside its raw params.
+type requestEnvelope struct {
+    Method string          `json:"method"`
+    Params json.RawMessage `json:"params"`
+}
+
+// httpForwarder is the original ZEROCLAW_URL webhook transport. It is
+// special-cased in handleZeroClawForward rather than called through the
+// Forwarder interface, because only it supports detecting and relaying a
+// chunked/SSE streaming upstream.
+type httpForwarder struct {
+    url   string
+    token string
+}
+
+func (h *httpForwarder) Forward(ctx context.Context, method string, params json.RawMessage) (json.RawMessage, error) {
+    body, _ := json.Marshal(requestEnvelope{Method: method, Params: params})
+    req, err := newZeroClawRequest(ctx, h.url, h.token, body)
+    if err != nil {
+        return nil, err
+    }
+    resp, err := httpClient.Do(req)
+    if err != nil {
+        return nil, err
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode >= 500 {
+        return nil, fmt.Errorf("upstream status %d", resp.StatusCode)
+    }
+    var payload json.RawMessage
+    if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+        return nil, err
+    }
+    return payload, nil
+}
+
+// unixForwarder sends a newline-delimited JSON request envelope over a Unix
+// domain socket and reads a single newline-delimited JSON reply, for
+// co-located deployments where ZeroClaw runs as a sidecar.
+type unixForwarder struct {
+    socketPath string
+    dialTimeout time.Duration
+}
+
+func (u *unixForwarder) Forward(ctx context.Context, method string, params json.RawMessage) (json.RawMessage, error) {
+    dialer := net.Dialer{Timeout: u.dialTimeout}
+    conn, err := dialer.DialContext(ctx, "unix", u.socketPath)
+    if err != nil {
+        return nil, err
+    }
+    defer conn.Close()
+    if deadline, ok := ctx.Deadline(); ok {
+        conn.SetDeadline(deadline)
+    }
+
+    body, err := json.Marshal(requestEnvelope{Method: method, Params: params})
+    if err != nil {
+        return nil, err
+    }
+    if _, err := conn.Write(append(body, '\n')); err != nil {
+        return nil, err
+    }
+
+    line, err := bufio.NewReader(conn).ReadBytes('\n')
+    if err != nil {
+        return nil, err
+    }
+    var payload json.RawMessage
+    if err := json.Unmarshal(bytes.TrimSpace(line), &payload); err != nil {
+        return nil, err
+    }
+    return payload, nil
+}
+
+// grpcForwarder calls a unary ZeroClaw.Invoke RPC. It speaks gRPC without
+// generated protobuf stubs by registering a "raw" codec (see rawJSONCodec)
+// that passes the request/response bytes through as opaque JSON, so it only
+// interoperates with a ZeroClaw endpoint that accepts that content-subtype.
+type grpcForwarder struct {
+    conn *grpc.ClientConn
+}
+
+func newGRPCForwarder(target string) (*grpcForwarder, error) {
+    conn, err := grpc.NewClient(target,
+        grpc.WithTransportCredentials(insecure.NewCredentials()),
+        grpc.WithDefaultCallOptions(grpc.CallContentSubtype(rawJSONCodecName)),
+    )
+    if err != nil {
+        return nil, err
+    }
+    return &grpcForwarder{conn: conn}, nil
+}
+
+func (g *grpcForwarder) Forward(ctx context.Context, method string, params json.RawMessage) (json.RawMessage, error) {
+    in, err := json.Marshal(requestEnvelope{Method: method, Params: params})
+    if err != nil {
+        return nil, err
+    }
+    var out json.RawMessage
+    if err := g.conn.Invoke(ctx, "/zeroclaw.ZeroClaw/Invoke", json.RawMessage(in), &out); err != nil {
+        return nil, fmt.Errorf("grpc invoke: %w", err)
+    }
+    return out, nil
+}
+
+const rawJSONCodecName = "raw"
+
+func init() {
+    encoding.RegisterCodec(rawJSONCodec{})
+}
+
+// rawJSONCodec marshals json.RawMessage payloads as opaque gRPC message
+// bytes instead of real protobuf wire format, so grpcForwarder can call
+// ZeroClaw.Invoke without a .proto toolchain.
+type rawJSONCodec struct{}
+
+func (rawJSONCodec) Name() string { return rawJSONCodecName }
+
+func (rawJSONCodec) Marshal(v any) ([]byte, error) {
+    if m, ok := v.(json.RawMessage); ok {
+        return m, nil
+    }
+    return json.Marshal(v)
+}
+
+func (rawJSONCodec) Unmarshal(data []byte, v any) error {
+    if p, ok := v.(*json.RawMessage); ok {
+        *p = append((*p)[:0:0], data...)
+        return nil
+    }
+    return json.Unmarshal(data, v)
+}
+
+// inProcessForwarder dispatches to handlers registered directly in the
+// process, keyed by exact method name. It exists so tests can exercise
+// routing and the forwarding path without a real upstream.
+type inProcessForwarder struct {
+    mu       sync.Mutex
+    handlers map[string]func(ctx context.Context, params json.RawMessage) (json.RawMessage, error)
+}
+
+func newInProcessForwarder() *inProcessForwarder {
+    return &inProcessForwarder{handlers: map[string]func(context.Context, json.RawMessage) (json.RawMessage, error){}}
+}
+
+func (r *inProcessForwarder) Register(method string, handler func(ctx context.Context, params json.RawMessage) (json.RawMessage, error)) {
+    r.mu.Lock()
+    r.handlers[method] = handler
+    r.mu.Unlock()
+}
+
+func (r *inProcessForwarder) Forward(ctx context.Context, method string, params json.RawMessage) (json.RawMessage, error) {
+    r.mu.Lock()
+    handler, ok := r.handlers[method]
+    r.mu.Unlock()
+    if !ok {
+        return nil, fmt.Errorf("no in-process handler registered for %q", method)
+    }
+    return handler(ctx, params)
+}
+
+// methodRoute pairs a method prefix with the forwarder that should handle
+// it. The routing table is checked in order; the first matching prefix
+// wins, so entries should be listed most-specific first.
+type methodRoute struct {
+    Prefix    string `json:"prefix"`
+    Transport string `json:"transport"`
+}
+
+var (
+    inProcessRegistry = newInProcessForwarder()
+    defaultForwarder  Forwarder
+    routingTable      []resolvedRoute
+)
+
+type resolvedRoute struct {
+    prefix    string
+    forwarder Forwarder
+}
+
+func init() {
+    defaultForwarder = buildForwarder(getenv("ZEROCLAW_TRANSPORT", "http"))
+    routingTable = loadRoutingTable(os.Getenv("ZEROCLAW_TRANSPORT_ROUTES_FILE"))
+}
+
+// buildForwarder constructs the Forwarder named by transport, defaulting to
+// the HTTP webhook transport for unknown names so misconfiguration degrades
+// to existing behavior rather than dropping all traffic.
+func buildForwarder(transport string) Forwarder {
+    switch transport {
+    case "grpc":
+        target := getenv("ZEROCLAW_GRPC_TARGET", "zeroclaw:9000")
+        fwd, err := newGRPCForwarder(target)
+        if err != nil {
+            logger.Error("grpc forwarder init failed, falling back to http", "error", err.Error())
+            break
+        }
+        return fwd
+    case "unix":
+        return &unixForwarder{
+            socketPath:  getenv("ZEROCLAW_UNIX_SOCKET", "/run/zeroclaw.sock"),
+            dialTimeout: 5 * time.Second,
+        }
+    case "inprocess":
+        return inProcessRegistry
+    case "http", "":
+    default:
+        logger.Error("unknown ZEROCLAW_TRANSPORT, falling back to http", "transport", transport)
+    }
+    return &httpForwarder{url: zeroclawURL, token: os.Getenv("ZEROCLAW_BEARER_TOKEN")}
+}
+
+func loadRoutingTable(path string) []resolvedRoute {
+    if path == "" {
+        return nil
+    }
+    raw, err := os.ReadFile(path)
+    if err != nil {
+        logger.Error("transport routes file", "error", err.Error())
+        return nil
+    }
+    var file struct {
+        Routes []methodRoute `json:"routes"`
+    }
+    if err := json.Unmarshal(raw, &file); err != nil {
+        logger.Error("transport routes file", "error", err.Error())
+        return nil
+    }
+
+    routes := make([]resolvedRoute, 0, len(file.Routes))
+    for _, r := range file.Routes {
+        routes = append(routes, resolvedRoute{prefix: r.Prefix, forwarder: buildForwarder(r.Transport)})
+    }
+    return routes
+}
+
+// forwarderFor picks the Forwarder configured for method, falling back to
+// defaultForwarder when no route prefix matches.
+func forwarderFor(method string) Forwarder {
+    for _, rt := range routingTable {
+        if strings.HasPrefix(method, rt.prefix) {
+            return rt.forwarder
+        }
+    }
+    return defaultForwarder
+}
+
+func newZeroClawRequest(ctx context.Context, url, token string, body []byte) (*http.Request, error) {
+    req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+    if err != nil {
+        return nil, err
+    }
+    req.Header.Set("Content-Type", "application/json")
+    if token != "" {
+        req.Header.Set("Authorization", "Bearer "+token)
+    }
+    return req, nil
+}
+
+var httpClient = &http.Client{}
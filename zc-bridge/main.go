@@ -1,21 +1,50 @@
 package main
 
 import (
-    "bytes"
+    "bufio"
+    "context"
+    "crypto/hmac"
+    "crypto/sha256"
+    "crypto/subtle"
+    "encoding/base64"
+    "encoding/hex"
     "encoding/json"
+    "errors"
+    "fmt"
+    "io"
     "log"
+    "log/slog"
+    "net"
     "net/http"
     "os"
+    "strconv"
+    "strings"
     "sync"
+    "sync/atomic"
     "time"
 
     "github.com/gorilla/websocket"
 )
 
 var (
-    addr        = ":18789"
-    zeroclawURL = getenv("ZEROCLAW_URL", "http://zeroclaw:3000/webhook")
-    upgrader    = websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
+    addr          = ":18789"
+    zeroclawURL   = getenv("ZEROCLAW_URL", "http://zeroclaw:3000/webhook")
+    authSecret    = os.Getenv("ZEROCLAW_AUTH_SECRET")
+    webhookSecret = os.Getenv("ZEROCLAW_WEBHOOK_SECRET")
+    authorizeURL  = os.Getenv("ZEROCLAW_AUTHORIZE_URL")
+    reauthEvery   = getenvDuration("ZEROCLAW_REAUTH_INTERVAL", 60*time.Second)
+    maxProofSkew  = 30 * time.Second
+    upgrader      = websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
+    metricsAddr   = os.Getenv("ZEROCLAW_METRICS_ADDR")
+
+    logger = slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+    connIDCounter int64
+)
+
+const (
+    pongWait     = 60 * time.Second
+    pingInterval = 30 * time.Second
 )
 
 type Frame struct {
@@ -35,19 +64,582 @@ type ErrPayload struct {
     Message string `json:"message"`
 }
 
+// connState holds the per-connection state handlers need beyond the
+// websocket itself: the write lock, and the in-flight requests that a
+// req.cancel frame can cancel.
+type connState struct {
+    id       string
+    ws       *websocket.Conn
+    writeMu  *sync.Mutex
+    remoteIP string
+
+    cancelsMu sync.Mutex
+    cancels   map[string]context.CancelFunc
+
+    subsMu sync.Mutex
+    subs   map[string]subscription
+}
+
+func (cs *connState) trackCancel(id string, cancel context.CancelFunc) {
+    cs.cancelsMu.Lock()
+    cs.cancels[id] = cancel
+    cs.cancelsMu.Unlock()
+}
+
+func (cs *connState) untrackCancel(id string) {
+    cs.cancelsMu.Lock()
+    delete(cs.cancels, id)
+    cs.cancelsMu.Unlock()
+}
+
+func (cs *connState) cancel(id string) {
+    cs.cancelsMu.Lock()
+    cancel, ok := cs.cancels[id]
+    cs.cancelsMu.Unlock()
+    if ok {
+        cancel()
+    }
+}
+
+// subscribeSession joins this connection to a session's hub, replays any
+// buffered events newer than sinceSeq, and starts forwarding live events. A
+// second subscribe for the same key replaces the first. It reports false,
+// without subscribing or creating the session, if key is new to this
+// connection and it's already at maxSessionSubsPerConn. The cap check,
+// getOrCreateSession, and the map insert all happen under the same
+// cs.subsMu critical section: the check has to come before
+// getOrCreateSession, not just before the insert, or a rejected subscribe
+// would still have permanently grown the global sessions map; and
+// concurrent subscribe calls on the same connection can't all pass the
+// check before any of them registers.
+func (cs *connState) subscribeSession(key string, sinceSeq int64) bool {
+    if key == "" {
+        return true
+    }
+
+    cs.subsMu.Lock()
+    _, exists := cs.subs[key]
+    if !exists && len(cs.subs) >= maxSessionSubsPerConn {
+        cs.subsMu.Unlock()
+        return false
+    }
+    s := getOrCreateSession(key)
+    ch, replay := s.subscribe(sinceSeq)
+    old, hadOld := cs.subs[key]
+    cs.subs[key] = subscription{session: s, ch: ch}
+    cs.subsMu.Unlock()
+    if hadOld {
+        old.session.unsubscribe(old.ch)
+    }
+
+    for _, fr := range replay {
+        safeWriteJSON(cs.ws, cs.writeMu, fr)
+    }
+    go cs.pumpSubscription(ch)
+    return true
+}
+
+func (cs *connState) unsubscribeSession(key string) {
+    cs.subsMu.Lock()
+    sub, ok := cs.subs[key]
+    delete(cs.subs, key)
+    cs.subsMu.Unlock()
+    if ok {
+        sub.session.unsubscribe(sub.ch)
+    }
+}
+
+// closeAllSubscriptions tears down every hub subscription held by this
+// connection; called when the websocket disconnects.
+func (cs *connState) closeAllSubscriptions() {
+    cs.subsMu.Lock()
+    subs := cs.subs
+    cs.subs = map[string]subscription{}
+    cs.subsMu.Unlock()
+    for _, sub := range subs {
+        sub.session.unsubscribe(sub.ch)
+    }
+}
+
+func (cs *connState) pumpSubscription(ch chan Frame) {
+    for fr := range ch {
+        safeWriteJSON(cs.ws, cs.writeMu, fr)
+    }
+}
+
+// subscription pairs a hub event channel with the session it belongs to, so
+// it can be unsubscribed without a second map lookup.
+type subscription struct {
+    session *Session
+    ch      chan Frame
+}
+
+// sessionEventBufSize bounds how many unconsumed events a session hub will
+// replay to a newly-subscribing client.
+const sessionEventBufSize = 64
+
+// maxSessionSubsPerConn bounds how many distinct session keys a single
+// connection may subscribe to at once, so a client can't grow the global
+// sessions map and spawn an unbounded number of pumpSubscription goroutines
+// by flooding sessions.subscribe with made-up keys.
+const maxSessionSubsPerConn = 32
+
 type Session struct {
     Key       string    `json:"key"`
     Status    string    `json:"status"`
     Model     string    `json:"model"`
     CreatedAt time.Time `json:"createdAt"`
+
+    mu   sync.Mutex
+    subs map[chan Frame]struct{}
+    buf  []Frame
+}
+
+// subscribe registers a new subscriber channel and returns it along with any
+// buffered events whose seq is greater than sinceSeq, so the caller can
+// replay history before live events start flowing on the channel.
+func (s *Session) subscribe(sinceSeq int64) (chan Frame, []Frame) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if s.subs == nil {
+        s.subs = map[chan Frame]struct{}{}
+    }
+    ch := make(chan Frame, 32)
+    s.subs[ch] = struct{}{}
+
+    var replay []Frame
+    for _, fr := range s.buf {
+        if fr.Seq > sinceSeq {
+            replay = append(replay, fr)
+        }
+    }
+    return ch, replay
+}
+
+func (s *Session) unsubscribe(ch chan Frame) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if _, ok := s.subs[ch]; ok {
+        delete(s.subs, ch)
+        close(ch)
+    }
+}
+
+// publish appends fr to the replay buffer and fans it out to every current
+// subscriber. A slow subscriber whose channel is full has the event dropped
+// rather than blocking the publisher.
+func (s *Session) publish(fr Frame) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    s.buf = append(s.buf, fr)
+    if len(s.buf) > sessionEventBufSize {
+        s.buf = s.buf[len(s.buf)-sessionEventBufSize:]
+    }
+
+    for ch := range s.subs {
+        select {
+        case ch <- fr:
+        default:
+        }
+    }
+}
+
+func getOrCreateSession(key string) *Session {
+    sessionsMu.Lock()
+    defer sessionsMu.Unlock()
+    s, ok := sessions[key]
+    if !ok {
+        s = &Session{Key: key, CreatedAt: time.Now()}
+        sessions[key] = s
+        activeSessionsGauge.Set(float64(len(sessions)))
+    }
+    return s
+}
+
+// methodPolicy overrides the default rate limit and concurrency for a single
+// RPC method, loaded from ZEROCLAW_POLICY_FILE.
+type methodPolicy struct {
+    RatePerSec  float64 `json:"ratePerSec"`
+    Burst       float64 `json:"burst"`
+    MaxInFlight int     `json:"maxInFlight"`
+}
+
+// policy holds the bridge's default forwarding limits plus any per-method
+// overrides. It is loaded once at startup from env vars and, optionally, a
+// JSON file (ZEROCLAW_POLICY_FILE) shaped as {"methods": {method: methodPolicy}}.
+type policy struct {
+    ratePerSec       float64
+    burst            float64
+    maxInFlight      int
+    upstreamTimeout  time.Duration
+    breakerThreshold int
+    breakerWindow    time.Duration
+    breakerCooldown  time.Duration
+    methodOverrides  map[string]methodPolicy
+}
+
+func loadPolicy() *policy {
+    p := &policy{
+        ratePerSec:       getenvFloat("ZEROCLAW_RATE_PER_SEC", 20),
+        burst:            getenvFloat("ZEROCLAW_RATE_BURST", 40),
+        maxInFlight:      getenvInt("ZEROCLAW_MAX_IN_FLIGHT", 8),
+        upstreamTimeout:  getenvDuration("ZEROCLAW_UPSTREAM_TIMEOUT", 10*time.Second),
+        breakerThreshold: getenvInt("ZEROCLAW_BREAKER_THRESHOLD", 5),
+        breakerWindow:    getenvDuration("ZEROCLAW_BREAKER_WINDOW", 30*time.Second),
+        breakerCooldown:  getenvDuration("ZEROCLAW_BREAKER_COOLDOWN", 15*time.Second),
+        methodOverrides:  map[string]methodPolicy{},
+    }
+
+    path := os.Getenv("ZEROCLAW_POLICY_FILE")
+    if path == "" {
+        return p
+    }
+    raw, err := os.ReadFile(path)
+    if err != nil {
+        logger.Error("policy file", "error", err.Error())
+        return p
+    }
+    var file struct {
+        Methods map[string]methodPolicy `json:"methods"`
+    }
+    if err := json.Unmarshal(raw, &file); err != nil {
+        logger.Error("policy file", "error", err.Error())
+        return p
+    }
+    p.methodOverrides = file.Methods
+    return p
+}
+
+func getenvFloat(k string, d float64) float64 {
+    v := os.Getenv(k)
+    if v == "" {
+        return d
+    }
+    f, err := strconv.ParseFloat(v, 64)
+    if err != nil {
+        return d
+    }
+    return f
+}
+
+func getenvInt(k string, d int) int {
+    v := os.Getenv(k)
+    if v == "" {
+        return d
+    }
+    n, err := strconv.Atoi(v)
+    if err != nil {
+        return d
+    }
+    return n
+}
+
+var pol = loadPolicy()
+
+// builtinMethods are the RPC methods handleRPC serves directly rather than
+// forwarding, so they get their own rate-limit/pool/breaker/metrics bucket
+// even though they never appear in methodOverrides or the routing table.
+var builtinMethods = map[string]bool{
+    "sessions.list":        true,
+    "sessions.subscribe":   true,
+    "sessions.unsubscribe": true,
+    "models.list":          true,
+    "bridge.stats":         true,
+}
+
+// canonicalMethod returns the key to use for a method's rate limiter, worker
+// pool, circuit breaker, and metrics label. A method only gets its own
+// bucket when it's builtin or the operator has explicitly named it (a policy
+// override or a routing prefix); every other, client-supplied method name
+// collapses into "other" so a client can't grow these registries and the
+// bridge's exported metric cardinality without bound by making up new
+// method strings.
+func canonicalMethod(method string) string {
+    if builtinMethods[method] {
+        return method
+    }
+    if _, ok := pol.methodOverrides[method]; ok {
+        return method
+    }
+    for _, rt := range routingTable {
+        if strings.HasPrefix(method, rt.prefix) {
+            return method
+        }
+    }
+    return "other"
+}
+
+// tokenBucket is a simple token-bucket rate limiter refilled lazily on allow().
+type tokenBucket struct {
+    mu       sync.Mutex
+    tokens   float64
+    max      float64
+    rate     float64
+    lastTime time.Time
+}
+
+func newTokenBucket(rate, burst float64) *tokenBucket {
+    return &tokenBucket{tokens: burst, max: burst, rate: rate, lastTime: time.Now()}
+}
+
+func (b *tokenBucket) allow() bool {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    now := time.Now()
+    b.tokens += now.Sub(b.lastTime).Seconds() * b.rate
+    if b.tokens > b.max {
+        b.tokens = b.max
+    }
+    b.lastTime = now
+    if b.tokens < 1 {
+        return false
+    }
+    b.tokens--
+    return true
+}
+
+// rateLimiterRegistry lazily creates one tokenBucket per key (client IP or
+// method name), using newFor to size it.
+type rateLimiterRegistry struct {
+    mu     sync.Mutex
+    byKey  map[string]*tokenBucket
+    newFor func(key string) *tokenBucket
+}
+
+func (r *rateLimiterRegistry) allow(key string) bool {
+    r.mu.Lock()
+    b, ok := r.byKey[key]
+    if !ok {
+        b = r.newFor(key)
+        r.byKey[key] = b
+    }
+    r.mu.Unlock()
+    return b.allow()
+}
+
+var (
+    ipLimiters = &rateLimiterRegistry{
+        byKey:  map[string]*tokenBucket{},
+        newFor: func(string) *tokenBucket { return newTokenBucket(pol.ratePerSec, pol.burst) },
+    }
+    methodLimiters = &rateLimiterRegistry{
+        byKey: map[string]*tokenBucket{},
+        newFor: func(method string) *tokenBucket {
+            if mp, ok := pol.methodOverrides[method]; ok && mp.RatePerSec > 0 {
+                return newTokenBucket(mp.RatePerSec, mp.Burst)
+            }
+            return newTokenBucket(pol.ratePerSec, pol.burst)
+        },
+    }
+)
+
+// methodPool bounds how many requests for a method may be in flight at once;
+// acquire blocks (queues) once the pool is saturated rather than rejecting.
+type methodPool struct {
+    sem    chan struct{}
+    queued int64
+}
+
+// acquire blocks until a slot is free or ctx is done, whichever comes first,
+// so a request queued behind a saturated pool can still be aborted by its
+// caller's timeout or a req.cancel frame instead of waiting indefinitely.
+func (p *methodPool) acquire(ctx context.Context) error {
+    select {
+    case p.sem <- struct{}{}:
+        return nil
+    default:
+    }
+    atomic.AddInt64(&p.queued, 1)
+    defer atomic.AddInt64(&p.queued, -1)
+    select {
+    case p.sem <- struct{}{}:
+        return nil
+    case <-ctx.Done():
+        return ctx.Err()
+    }
+}
+
+func (p *methodPool) release() { <-p.sem }
+
+type poolRegistry struct {
+    mu    sync.Mutex
+    pools map[string]*methodPool
+}
+
+func (pr *poolRegistry) forMethod(method string) *methodPool {
+    pr.mu.Lock()
+    defer pr.mu.Unlock()
+    p, ok := pr.pools[method]
+    if !ok {
+        max := pol.maxInFlight
+        if mp, exists := pol.methodOverrides[method]; exists && mp.MaxInFlight > 0 {
+            max = mp.MaxInFlight
+        }
+        p = &methodPool{sem: make(chan struct{}, max)}
+        pr.pools[method] = p
+    }
+    return p
+}
+
+var methodPools = &poolRegistry{pools: map[string]*methodPool{}}
+
+const (
+    breakerClosed   = "closed"
+    breakerOpen     = "open"
+    breakerHalfOpen = "half-open"
+)
+
+// circuitBreaker trips to "open" after breakerThreshold consecutive upstream
+// failures inside breakerWindow, then allows a single half-open probe once
+// breakerCooldown has elapsed.
+type circuitBreaker struct {
+    mu              sync.Mutex
+    state           string
+    consecutiveFail int
+    windowStart     time.Time
+    openedAt        time.Time
+}
+
+// allow reports whether a request may proceed. Once the cooldown elapses
+// after tripping open, exactly one caller is admitted as the half-open
+// probe (the one that flips state to breakerHalfOpen); every other caller
+// is rejected until that probe's result is recorded via recordSuccess or
+// recordFailure, which moves state back to closed or open.
+func (cb *circuitBreaker) allow() bool {
+    cb.mu.Lock()
+    defer cb.mu.Unlock()
+    switch cb.state {
+    case breakerOpen:
+        if time.Since(cb.openedAt) < pol.breakerCooldown {
+            return false
+        }
+        cb.state = breakerHalfOpen
+        return true
+    case breakerHalfOpen:
+        return false
+    default:
+        return true
+    }
+}
+
+func (cb *circuitBreaker) recordSuccess() {
+    cb.mu.Lock()
+    defer cb.mu.Unlock()
+    cb.state = breakerClosed
+    cb.consecutiveFail = 0
+}
+
+func (cb *circuitBreaker) recordFailure() {
+    cb.mu.Lock()
+    defer cb.mu.Unlock()
+
+    if cb.state == breakerHalfOpen {
+        cb.state = breakerOpen
+        cb.openedAt = time.Now()
+        return
+    }
+
+    now := time.Now()
+    if cb.consecutiveFail == 0 || now.Sub(cb.windowStart) > pol.breakerWindow {
+        cb.windowStart = now
+        cb.consecutiveFail = 0
+    }
+    cb.consecutiveFail++
+    if cb.consecutiveFail >= pol.breakerThreshold {
+        cb.state = breakerOpen
+        cb.openedAt = now
+    }
+}
+
+type breakerRegistry struct {
+    mu       sync.Mutex
+    breakers map[string]*circuitBreaker
+}
+
+func (br *breakerRegistry) forMethod(method string) *circuitBreaker {
+    br.mu.Lock()
+    defer br.mu.Unlock()
+    cb, ok := br.breakers[method]
+    if !ok {
+        cb = &circuitBreaker{state: breakerClosed}
+        br.breakers[method] = cb
+    }
+    return cb
+}
+
+var breakers = &breakerRegistry{breakers: map[string]*circuitBreaker{}}
+
+// bridgeStats reports per-method breaker state, in-flight count, and queue
+// depth for the bridge.stats RPC so operators can observe backpressure.
+func bridgeStats() map[string]any {
+    methods := map[string]any{}
+
+    breakers.mu.Lock()
+    for method, cb := range breakers.breakers {
+        cb.mu.Lock()
+        methods[method] = map[string]any{"breakerState": cb.state}
+        cb.mu.Unlock()
+    }
+    breakers.mu.Unlock()
+
+    methodPools.mu.Lock()
+    for method, p := range methodPools.pools {
+        entry, ok := methods[method].(map[string]any)
+        if !ok {
+            entry = map[string]any{}
+            methods[method] = entry
+        }
+        entry["inFlight"] = len(p.sem)
+        entry["maxInFlight"] = cap(p.sem)
+        entry["queueDepth"] = atomic.LoadInt64(&p.queued)
+    }
+    methodPools.mu.Unlock()
+
+    return map[string]any{"methods": methods}
+}
+
+func clientIP(r *http.Request) string {
+    host, _, err := net.SplitHostPort(r.RemoteAddr)
+    if err != nil {
+        return r.RemoteAddr
+    }
+    return host
 }
 
 var (
     sessions   = map[string]*Session{}
     sessionsMu sync.Mutex
     seq        int64
+
+    activeConns   = map[*connState]struct{}{}
+    activeConnsMu sync.Mutex
 )
 
+func registerConn(cs *connState) {
+    activeConnsMu.Lock()
+    activeConns[cs] = struct{}{}
+    activeConnsMu.Unlock()
+    wsConnections.Inc()
+}
+
+func unregisterConn(cs *connState) {
+    activeConnsMu.Lock()
+    delete(activeConns, cs)
+    activeConnsMu.Unlock()
+    wsConnections.Dec()
+}
+
+// broadcastToAll sends fr to every currently-connected client, regardless of
+// session subscription. Used for inbound webhook events with no sessionKey.
+func broadcastToAll(fr Frame) {
+    activeConnsMu.Lock()
+    defer activeConnsMu.Unlock()
+    for cs := range activeConns {
+        safeWriteJSON(cs.ws, cs.writeMu, fr)
+    }
+}
+
 func getenv(k, d string) string {
     v := os.Getenv(k)
     if v == "" {
@@ -56,14 +648,28 @@ func getenv(k, d string) string {
     return v
 }
 
+func getenvDuration(k string, d time.Duration) time.Duration {
+    v := os.Getenv(k)
+    if v == "" {
+        return d
+    }
+    secs, err := strconv.Atoi(v)
+    if err != nil {
+        return d
+    }
+    return time.Duration(secs) * time.Second
+}
+
 func mustJSON(v any) json.RawMessage {
     b, _ := json.Marshal(v)
     return b
 }
 
+// nextSeq is called concurrently from per-connection RPC goroutines and the
+// inbound webhook handler, so it must hand out values atomically rather
+// than racing on a plain increment.
 func nextSeq() int64 {
-    seq++
-    return seq
+    return atomic.AddInt64(&seq, 1)
 }
 
 func safeWriteJSON(ws *websocket.Conn, mu *sync.Mutex, v any) error {
@@ -79,66 +685,423 @@ func safeWriteControl(ws *websocket.Conn, mu *sync.Mutex, messageType int, data
 }
 
 func main() {
+    if metricsAddr != "" {
+        startMetricsServer(metricsAddr)
+    }
+
     http.HandleFunc("/", handleWS)
-    log.Println("zc-bridge listening on", addr)
+    http.HandleFunc("/webhook/inbound", handleInboundWebhook)
+    logger.Info("zc-bridge listening", "addr", addr)
     log.Fatal(http.ListenAndServe(addr, nil))
 }
 
+// inboundWebhookPayload is the body ZeroClaw posts to /webhook/inbound to
+// push an asynchronous event (session lifecycle, tool-call result, model
+// progress) into the bridge for fan-out over websockets.
+type inboundWebhookPayload struct {
+    Event      string          `json:"event"`
+    SessionKey string          `json:"sessionKey"`
+    Payload    json.RawMessage `json:"payload"`
+}
+
+// handleInboundWebhook authenticates an inbound push from the ZeroClaw
+// backend (bearer token plus an HMAC-SHA256 signature over the raw body,
+// with a 5-minute timestamp skew window to block replay) and fans the event
+// out as an "event" frame: to the named session's subscribers if SessionKey
+// is set, or to every connected client otherwise.
+func handleInboundWebhook(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    body, err := io.ReadAll(r.Body)
+    if err != nil {
+        http.Error(w, "bad request", http.StatusBadRequest)
+        return
+    }
+
+    if !verifyWebhookBearer(r) || !verifyWebhookSignature(r, body) {
+        http.Error(w, "unauthorized", http.StatusUnauthorized)
+        return
+    }
+
+    var in inboundWebhookPayload
+    if err := json.Unmarshal(body, &in); err != nil {
+        http.Error(w, "bad request", http.StatusBadRequest)
+        return
+    }
+
+    fr := Frame{
+        Type:    "event",
+        Event:   in.Event,
+        Seq:     nextSeq(),
+        Payload: in.Payload,
+    }
+    if in.SessionKey != "" {
+        getOrCreateSession(in.SessionKey).publish(fr)
+    } else {
+        broadcastToAll(fr)
+    }
+
+    w.WriteHeader(http.StatusNoContent)
+}
+
+// verifyWebhookBearer requires ZEROCLAW_BEARER_TOKEN to be configured and
+// rejects the request outright when it isn't, rather than treating an
+// unconfigured secret as "no check needed" for an internet-reachable
+// endpoint.
+func verifyWebhookBearer(r *http.Request) bool {
+    token := os.Getenv("ZEROCLAW_BEARER_TOKEN")
+    if token == "" {
+        logger.Error("webhook rejected: ZEROCLAW_BEARER_TOKEN is not configured")
+        return false
+    }
+    got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+    return subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+// verifyWebhookSignature checks the X-ZeroClaw-Signature header against an
+// HMAC-SHA256 of "timestamp.body" using webhookSecret (a secret distinct
+// from authSecret: authSecret authenticates websocket clients, webhookSecret
+// authenticates the ZeroClaw backend, and the two trust boundaries must not
+// share a key). It requires webhookSecret to be configured and rejects
+// requests whose X-ZeroClaw-Timestamp is more than 5 minutes away from now.
+func verifyWebhookSignature(r *http.Request, body []byte) bool {
+    if webhookSecret == "" {
+        logger.Error("webhook rejected: ZEROCLAW_WEBHOOK_SECRET is not configured")
+        return false
+    }
+
+    ts := r.Header.Get("X-ZeroClaw-Timestamp")
+    sig := r.Header.Get("X-ZeroClaw-Signature")
+    if ts == "" || sig == "" {
+        return false
+    }
+
+    tsUnix, err := strconv.ParseInt(ts, 10, 64)
+    if err != nil {
+        return false
+    }
+    if skew := time.Since(time.Unix(tsUnix, 0)); skew > 5*time.Minute || skew < -5*time.Minute {
+        return false
+    }
+
+    mac := hmac.New(sha256.New, []byte(webhookSecret))
+    mac.Write([]byte(ts))
+    mac.Write([]byte("."))
+    mac.Write(body)
+    expected := hex.EncodeToString(mac.Sum(nil))
+    return subtle.ConstantTimeCompare([]byte(expected), []byte(sig)) == 1
+}
+
 func handleWS(w http.ResponseWriter, r *http.Request) {
     ws, err := upgrader.Upgrade(w, r, nil)
     if err != nil {
-        log.Println(err)
+        logger.Error("upgrade failed", "error", err.Error())
         return
     }
     defer ws.Close()
 
+    connID := strconv.FormatInt(atomic.AddInt64(&connIDCounter, 1), 10)
+
+    ws.SetReadDeadline(time.Now().Add(pongWait))
+    ws.SetPongHandler(func(string) error {
+        ws.SetReadDeadline(time.Now().Add(pongWait))
+        return nil
+    })
+
     writeMu := &sync.Mutex{}
+    done := make(chan struct{})
+    defer close(done)
 
-    log.Println("client connected")
+    logger.Info("client connected", "conn_id", connID, "remote_ip", clientIP(r))
 
+    nonce := time.Now().UnixNano()
     safeWriteJSON(ws, writeMu, Frame{
         Type:  "event",
         Event: "connect.challenge",
         Payload: mustJSON(map[string]any{
-            "nonce": time.Now().UnixNano(),
+            "nonce": nonce,
         }),
     })
 
+    var credential string
+    var authorized bool
     for {
         var f Frame
         if err := ws.ReadJSON(&f); err != nil {
             return
         }
-        if f.Type == "req" && f.Method == "connect" {
-            safeWriteJSON(ws, writeMu, Frame{Type: "res", ID: f.ID, Ok: true})
-            break
+        if f.Type != "req" || f.Method != "connect" {
+            continue
+        }
+        var reauthable bool
+        credential, reauthable, authorized = verifyConnectProof(nonce, f.Params)
+        if !authorized {
+            sendError(ws, writeMu, f.ID, "authentication failed")
+            closeWithReason(ws, writeMu, 4001, "auth_failed")
+            return
         }
+        if authorizeURL != "" && !reauthable {
+            // The HMAC connect-proof is only meaningful within its original
+            // skew window and carries no identity an authorize endpoint can
+            // check later, so a deployment that configures ZEROCLAW_AUTHORIZE_URL
+            // cannot authorize this client type at all.
+            sendError(ws, writeMu, f.ID, "authorization required: use a bearer token")
+            closeWithReason(ws, writeMu, 4001, "auth_failed")
+            return
+        }
+        safeWriteJSON(ws, writeMu, Frame{Type: "res", ID: f.ID, Ok: true})
+        break
     }
 
-    log.Println("gateway authenticated")
+    var baseline *authorizeResult
+    if authorizeURL != "" {
+        result, status, err := authorizeCheck(credential)
+        if err != nil || status < 200 || status >= 300 {
+            closeWithReason(ws, writeMu, 4002, "authorize_failed")
+            return
+        }
+        baseline = result
+    }
 
-    go heartbeat(ws, writeMu)
+    logger.Info("gateway authenticated", "conn_id", connID)
+
+    cs := &connState{
+        id:       connID,
+        ws:       ws,
+        writeMu:  writeMu,
+        remoteIP: clientIP(r),
+        cancels:  map[string]context.CancelFunc{},
+        subs:     map[string]subscription{},
+    }
+    defer cs.closeAllSubscriptions()
+    registerConn(cs)
+    defer unregisterConn(cs)
+
+    go heartbeat(ws, writeMu, done)
+    if authorizeURL != "" && reauthEvery > 0 {
+        go reauthorizeLoop(ws, writeMu, done, credential, baseline)
+    }
 
     for {
         var f Frame
         if err := ws.ReadJSON(&f); err != nil {
+            if ne, ok := err.(net.Error); ok && ne.Timeout() {
+                heartbeatMissesTotal.Inc()
+                logger.Info("heartbeat missed, closing", "conn_id", connID)
+            }
             return
         }
-        if f.Type != "req" {
-            continue
+        switch f.Type {
+        case "req":
+            go runRPC(cs, f)
+        case "req.cancel":
+            cs.cancel(f.ID)
+        }
+    }
+}
+
+// runRPC dispatches f, then records its latency and outcome as Prometheus
+// metrics and a structured log line. Metrics are labeled with canonicalMethod
+// rather than the raw method name, so a client can't blow up label
+// cardinality by sending RPCs for made-up methods; the log line keeps the
+// raw name since log lines don't carry that cost.
+func runRPC(cs *connState, f Frame) {
+    start := time.Now()
+    ok := handleRPC(cs, f)
+    dur := time.Since(start)
+
+    method := canonicalMethod(f.Method)
+    rpcDurationSeconds.WithLabelValues(method).Observe(dur.Seconds())
+    rpcRequestsTotal.WithLabelValues(method, strconv.FormatBool(ok)).Inc()
+    logger.Info("rpc handled",
+        "conn_id", cs.id,
+        "req_id", f.ID,
+        "method", f.Method,
+        "duration_ms", dur.Milliseconds(),
+        "ok", ok,
+    )
+}
+
+func heartbeat(ws *websocket.Conn, writeMu *sync.Mutex, done <-chan struct{}) {
+    t := time.NewTicker(pingInterval)
+    defer t.Stop()
+    for {
+        select {
+        case <-t.C:
+            safeWriteControl(ws, writeMu, websocket.PingMessage, []byte("ping"), time.Now().Add(2*time.Second))
+        case <-done:
+            return
+        }
+    }
+}
+
+// reauthorizeLoop periodically re-checks credential against authorizeURL and
+// tears down the connection with a close frame the moment it is revoked or
+// its connection parameters (allowed methods, target URL, model list) drift
+// from the baseline established at connect time.
+func reauthorizeLoop(ws *websocket.Conn, writeMu *sync.Mutex, done <-chan struct{}, credential string, baseline *authorizeResult) {
+    t := time.NewTicker(reauthEvery)
+    defer t.Stop()
+    for {
+        select {
+        case <-t.C:
+            result, status, err := authorizeCheck(credential)
+            if err != nil || status < 200 || status >= 300 {
+                closeWithReason(ws, writeMu, 4001, "revoked")
+                return
+            }
+            if baseline != nil && !result.equal(baseline) {
+                closeWithReason(ws, writeMu, 4002, "params_changed")
+                return
+            }
+        case <-done:
+            return
+        }
+    }
+}
+
+func closeWithReason(ws *websocket.Conn, writeMu *sync.Mutex, code int, reason string) {
+    safeWriteControl(ws, writeMu, websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(2*time.Second))
+    ws.Close()
+}
+
+// connectProof is the body of the client's "connect" req.params: either an
+// HMAC proof over the server-issued nonce plus a timestamp, or a bearer JWT.
+type connectProof struct {
+    Proof string `json:"proof"`
+    Ts    int64  `json:"ts"`
+    Token string `json:"token"`
+}
+
+// authorizeResult is the shape returned by ZEROCLAW_AUTHORIZE_URL.
+type authorizeResult struct {
+    AllowedMethods []string `json:"allowedMethods"`
+    TargetURL      string   `json:"targetURL"`
+    Models         []string `json:"models"`
+}
+
+func (a *authorizeResult) equal(b *authorizeResult) bool {
+    if a.TargetURL != b.TargetURL {
+        return false
+    }
+    if len(a.AllowedMethods) != len(b.AllowedMethods) || len(a.Models) != len(b.Models) {
+        return false
+    }
+    for i := range a.AllowedMethods {
+        if a.AllowedMethods[i] != b.AllowedMethods[i] {
+            return false
+        }
+    }
+    for i := range a.Models {
+        if a.Models[i] != b.Models[i] {
+            return false
         }
-        go handleRPC(ws, writeMu, f)
     }
+    return true
+}
+
+// verifyConnectProof validates the client's response to connect.challenge and
+// returns a credential string together with whether that credential is
+// reauthable: usable by authorizeCheck/reauthorizeLoop to ask
+// ZEROCLAW_AUTHORIZE_URL about this client again later. A bearer JWT is
+// reauthable since it's a stable, standalone identity token. The HMAC proof
+// is not: it's the server's own digest of this connection's nonce and
+// timestamp, valid only inside maxProofSkew of the original handshake, so
+// replaying it as a Bearer credential after the fact proves nothing.
+func verifyConnectProof(nonce int64, params json.RawMessage) (credential string, reauthable bool, ok bool) {
+    var p connectProof
+    if len(params) > 0 {
+        if err := json.Unmarshal(params, &p); err != nil {
+            return "", false, false
+        }
+    }
+
+    if p.Token != "" {
+        if !verifyJWT(p.Token) {
+            return "", false, false
+        }
+        return p.Token, true, true
+    }
+
+    if p.Proof == "" || authSecret == "" {
+        return "", false, false
+    }
+    if skew := time.Since(time.Unix(p.Ts, 0)); skew > maxProofSkew || skew < -maxProofSkew {
+        return "", false, false
+    }
+
+    mac := hmac.New(sha256.New, []byte(authSecret))
+    fmt.Fprintf(mac, "%d:%d", nonce, p.Ts)
+    expected := hex.EncodeToString(mac.Sum(nil))
+    if subtle.ConstantTimeCompare([]byte(expected), []byte(p.Proof)) != 1 {
+        return "", false, false
+    }
+    return p.Proof, false, true
+}
+
+// verifyJWT checks an HS256 JWT's signature against authSecret and rejects
+// expired tokens. It does not support other algorithms.
+func verifyJWT(token string) bool {
+    if authSecret == "" {
+        return false
+    }
+    parts := strings.Split(token, ".")
+    if len(parts) != 3 {
+        return false
+    }
+    mac := hmac.New(sha256.New, []byte(authSecret))
+    mac.Write([]byte(parts[0] + "." + parts[1]))
+    expected := base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+    if subtle.ConstantTimeCompare([]byte(expected), []byte(parts[2])) != 1 {
+        return false
+    }
+
+    payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+    if err != nil {
+        return false
+    }
+    var claims struct {
+        Exp int64 `json:"exp"`
+    }
+    if err := json.Unmarshal(payload, &claims); err != nil {
+        return false
+    }
+    if claims.Exp != 0 && time.Now().Unix() > claims.Exp {
+        return false
+    }
+    return true
 }
 
-func heartbeat(ws *websocket.Conn, writeMu *sync.Mutex) {
-    t := time.NewTicker(30 * time.Second)
-    for range t.C {
-        safeWriteControl(ws, writeMu, websocket.PingMessage, []byte("ping"), time.Now().Add(2*time.Second))
+// authorizeCheck calls ZEROCLAW_AUTHORIZE_URL with the client's credential
+// and returns the decoded connection parameters along with the HTTP status.
+func authorizeCheck(credential string) (*authorizeResult, int, error) {
+    req, err := http.NewRequest("GET", authorizeURL, nil)
+    if err != nil {
+        return nil, 0, err
+    }
+    req.Header.Set("Authorization", "Bearer "+credential)
+
+    resp, err := http.DefaultClient.Do(req)
+    if err != nil {
+        return nil, 0, err
+    }
+    defer resp.Body.Close()
+
+    var result authorizeResult
+    if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+        if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+            return nil, resp.StatusCode, err
+        }
     }
+    return &result, resp.StatusCode, nil
 }
 
-func handleRPC(ws *websocket.Conn, writeMu *sync.Mutex, f Frame) {
+// handleRPC dispatches f to its handler and reports whether it succeeded,
+// for runRPC's metrics and logging.
+func handleRPC(cs *connState, f Frame) bool {
     switch f.Method {
 
     case "sessions.list":
@@ -149,15 +1112,16 @@ func handleRPC(ws *websocket.Conn, writeMu *sync.Mutex, f Frame) {
         }
         sessionsMu.Unlock()
 
-        safeWriteJSON(ws, writeMu, Frame{
+        safeWriteJSON(cs.ws, cs.writeMu, Frame{
             Type:    "res",
             ID:      f.ID,
             Ok:      true,
             Payload: mustJSON(map[string]any{"sessions": list}),
         })
+        return true
 
     case "models.list":
-        safeWriteJSON(ws, writeMu, Frame{
+        safeWriteJSON(cs.ws, cs.writeMu, Frame{
             Type:    "res",
             ID:      f.ID,
             Ok:      true,
@@ -165,65 +1129,302 @@ func handleRPC(ws *websocket.Conn, writeMu *sync.Mutex, f Frame) {
                 "models": []string{"kimi-k2.5"},
             }),
         })
+        return true
+
+    case "sessions.subscribe":
+        if !ipLimiters.allow(cs.remoteIP) || !methodLimiters.allow(canonicalMethod(f.Method)) {
+            sendErrorCode(cs.ws, cs.writeMu, f.ID, "rate_limited", "rate limit exceeded")
+            return false
+        }
+        var p struct {
+            Key      string `json:"key"`
+            SinceSeq int64  `json:"sinceSeq"`
+        }
+        json.Unmarshal(f.Params, &p)
+
+        if !cs.subscribeSession(p.Key, p.SinceSeq) {
+            sendErrorCode(cs.ws, cs.writeMu, f.ID, "too_many_subscriptions", "subscription limit reached")
+            return false
+        }
+        safeWriteJSON(cs.ws, cs.writeMu, Frame{Type: "res", ID: f.ID, Ok: true})
+        return true
+
+    case "sessions.unsubscribe":
+        if !ipLimiters.allow(cs.remoteIP) || !methodLimiters.allow(canonicalMethod(f.Method)) {
+            sendErrorCode(cs.ws, cs.writeMu, f.ID, "rate_limited", "rate limit exceeded")
+            return false
+        }
+        var p struct {
+            Key string `json:"key"`
+        }
+        json.Unmarshal(f.Params, &p)
+        cs.unsubscribeSession(p.Key)
+        safeWriteJSON(cs.ws, cs.writeMu, Frame{Type: "res", ID: f.ID, Ok: true})
+        return true
+
+    case "bridge.stats":
+        safeWriteJSON(cs.ws, cs.writeMu, Frame{
+            Type:    "res",
+            ID:      f.ID,
+            Ok:      true,
+            Payload: mustJSON(bridgeStats()),
+        })
+        return true
 
     default:
-        handleZeroClawForward(ws, writeMu, f)
+        return handleZeroClawForward(cs, f)
     }
 }
 
-func handleZeroClawForward(ws *websocket.Conn, writeMu *sync.Mutex, f Frame) {
+// sessionKeyOf extracts the session key an RPC and its response concern, so
+// the resulting session.updated event can be routed to the right hub. It
+// checks the request params first, then falls back to a "key" field on the
+// response payload.
+func sessionKeyOf(params json.RawMessage, payload any) string {
+    var p struct {
+        Key        string `json:"key"`
+        SessionKey string `json:"sessionKey"`
+    }
+    if len(params) > 0 {
+        json.Unmarshal(params, &p)
+    }
+    if p.Key != "" {
+        return p.Key
+    }
+    if p.SessionKey != "" {
+        return p.SessionKey
+    }
+    if m, ok := payload.(map[string]any); ok {
+        if k, ok := m["key"].(string); ok {
+            return k
+        }
+    }
+    return ""
+}
 
-    body := map[string]any{
-        "method": f.Method,
-        "params": json.RawMessage(f.Params),
+// handleZeroClawForward proxies an RPC to the ZeroClaw backend. If the
+// upstream response is chunked or text/event-stream, each record is relayed
+// as a res.chunk frame followed by a final res.end; otherwise the whole body
+// is sent as a single res frame, same as before. The request is tracked in
+// cs.cancels so a req.cancel frame for f.ID can abort it mid-flight.
+func handleZeroClawForward(cs *connState, f Frame) bool {
+    method := canonicalMethod(f.Method)
+    if !ipLimiters.allow(cs.remoteIP) || !methodLimiters.allow(method) {
+        sendErrorCode(cs.ws, cs.writeMu, f.ID, "rate_limited", "rate limit exceeded")
+        return false
     }
 
-    j, _ := json.Marshal(body)
+    cb := breakers.forMethod(method)
+    if !cb.allow() {
+        sendErrorCode(cs.ws, cs.writeMu, f.ID, "upstream_unavailable", "circuit breaker open")
+        return false
+    }
 
-    req, err := http.NewRequest("POST", zeroclawURL, bytes.NewReader(j))
-    if err != nil {
-        sendError(ws, writeMu, f.ID, err.Error())
-        return
+    // The timeout clock starts and the cancel func is registered before
+    // pool.acquire(), not after: a request queued behind a saturated pool
+    // must still be abortable by a req.cancel frame, and must not wait
+    // past upstreamTimeout just because it was stuck in the queue.
+    ctx, cancel := context.WithTimeout(context.Background(), pol.upstreamTimeout)
+    cs.trackCancel(f.ID, cancel)
+    defer func() {
+        cancel()
+        cs.untrackCancel(f.ID)
+    }()
+
+    pool := methodPools.forMethod(method)
+    if err := pool.acquire(ctx); err != nil {
+        if errors.Is(err, context.DeadlineExceeded) {
+            sendErrorCode(cs.ws, cs.writeMu, f.ID, "timeout", "request timed out while queued")
+        } else {
+            sendErrorCode(cs.ws, cs.writeMu, f.ID, "canceled", "request canceled while queued")
+        }
+        return false
     }
-    req.Header.Set("Content-Type", "application/json")
+    defer pool.release()
 
-    token := os.Getenv("ZEROCLAW_BEARER_TOKEN")
-    if token != "" {
-        req.Header.Set("Authorization", "Bearer "+token)
+    // The HTTP transport is special-cased rather than dispatched through
+    // Forwarder because it alone can detect and relay a chunked/SSE
+    // streaming upstream; see httpForwarder's doc comment.
+    if hf, ok := forwarderFor(f.Method).(*httpForwarder); ok {
+        return forwardViaHTTP(cs, f, ctx, cb, hf)
     }
+    return forwardViaInterface(cs, f, ctx, cb, forwarderFor(f.Method))
+}
 
-    resp, err := http.DefaultClient.Do(req)
+// forwardViaHTTP preserves the bridge's original behavior: a single POST to
+// a ZeroClaw webhook, with streaming detection for chunked/SSE responses.
+func forwardViaHTTP(cs *connState, f Frame, ctx context.Context, cb *circuitBreaker, hf *httpForwarder) bool {
+    body, _ := json.Marshal(requestEnvelope{Method: f.Method, Params: f.Params})
+
+    req, err := newZeroClawRequest(ctx, hf.url, hf.token, body)
     if err != nil {
-        sendError(ws, writeMu, f.ID, err.Error())
-        return
+        cb.recordFailure()
+        upstreamErrorsTotal.Inc()
+        sendError(cs.ws, cs.writeMu, f.ID, err.Error())
+        return false
+    }
+
+    resp, err := httpClient.Do(req)
+    if err != nil {
+        cb.recordFailure()
+        upstreamErrorsTotal.Inc()
+        sendError(cs.ws, cs.writeMu, f.ID, err.Error())
+        return false
     }
     defer resp.Body.Close()
 
+    if resp.StatusCode >= 500 {
+        cb.recordFailure()
+        upstreamErrorsTotal.Inc()
+    } else {
+        cb.recordSuccess()
+    }
+    logger.Info("upstream forwarded",
+        "conn_id", cs.id,
+        "req_id", f.ID,
+        "method", f.Method,
+        "upstream_status", resp.StatusCode,
+    )
+
+    if isStreaming(resp) {
+        streamZeroClawResponse(cs, f.ID, resp)
+        return resp.StatusCode < 500
+    }
+
     var payload any
     json.NewDecoder(resp.Body).Decode(&payload)
+    publishRPCResult(cs, f, mustJSON(payload), payload)
+    return true
+}
 
-    safeWriteJSON(ws, writeMu, Frame{
+// forwardViaInterface calls a non-streaming Forwarder (grpc, unix, or
+// in-process) and relays its payload the same way forwardViaHTTP does for
+// the non-streaming case.
+func forwardViaInterface(cs *connState, f Frame, ctx context.Context, cb *circuitBreaker, fwd Forwarder) bool {
+    raw, err := fwd.Forward(ctx, f.Method, f.Params)
+    if err != nil {
+        cb.recordFailure()
+        upstreamErrorsTotal.Inc()
+        sendError(cs.ws, cs.writeMu, f.ID, err.Error())
+        return false
+    }
+    cb.recordSuccess()
+
+    var payload any
+    json.Unmarshal(raw, &payload)
+    publishRPCResult(cs, f, raw, payload)
+    return true
+}
+
+// publishRPCResult sends the res frame for a forwarded RPC, then delivers
+// the resulting session.updated event to the triggering connection and fans
+// it out through the session hub when the request or payload identifies a
+// session key. cs is skipped in the direct delivery only when it's already
+// subscribed to that key, since subscribing would otherwise deliver the same
+// event to it twice; the subscription check runs before publish rather than
+// after, to keep the race against a concurrent sessions.subscribe on the
+// same key as narrow as possible.
+func publishRPCResult(cs *connState, f Frame, rawPayload json.RawMessage, decodedPayload any) {
+    safeWriteJSON(cs.ws, cs.writeMu, Frame{
         Type:    "res",
         ID:      f.ID,
         Ok:      true,
-        Payload: mustJSON(payload),
+        Payload: rawPayload,
     })
 
-    safeWriteJSON(ws, writeMu, Frame{
+    updated := Frame{
         Type:    "event",
         Event:   "session.updated",
         Seq:     nextSeq(),
-        Payload: mustJSON(payload),
-    })
+        Payload: rawPayload,
+    }
+    key := sessionKeyOf(f.Params, decodedPayload)
+    if key == "" {
+        safeWriteJSON(cs.ws, cs.writeMu, updated)
+        return
+    }
+
+    cs.subsMu.Lock()
+    _, alreadySubscribed := cs.subs[key]
+    cs.subsMu.Unlock()
+
+    getOrCreateSession(key).publish(updated)
+    if !alreadySubscribed {
+        safeWriteJSON(cs.ws, cs.writeMu, updated)
+    }
+}
+
+// isStreaming reports whether resp should be relayed incrementally rather
+// than buffered: chunked transfer encoding or an SSE content type.
+func isStreaming(resp *http.Response) bool {
+    for _, te := range resp.TransferEncoding {
+        if te == "chunked" {
+            return true
+        }
+    }
+    return strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream")
+}
+
+// streamZeroClawResponse relays a chunked or SSE upstream body as a sequence
+// of res.chunk frames followed by a res.end, honoring cancellation via the
+// request's context (resp.Body reads unblock once ctx is done because the
+// request was built with http.NewRequestWithContext).
+func streamZeroClawResponse(cs *connState, id string, resp *http.Response) {
+    sse := strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream")
+    scanner := bufio.NewScanner(resp.Body)
+    scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+    var chunkSeq int64
+    var lastErr error
+
+    for scanner.Scan() {
+        line := scanner.Text()
+        if sse {
+            if !strings.HasPrefix(line, "data:") {
+                continue
+            }
+            line = strings.TrimPrefix(line, "data:")
+            line = strings.TrimSpace(line)
+        }
+        if line == "" {
+            continue
+        }
+
+        var record any
+        if err := json.Unmarshal([]byte(line), &record); err != nil {
+            record = line
+        }
+
+        chunkSeq++
+        safeWriteJSON(cs.ws, cs.writeMu, Frame{
+            Type:    "res.chunk",
+            ID:      id,
+            Seq:     chunkSeq,
+            Payload: mustJSON(record),
+        })
+    }
+    if err := scanner.Err(); err != nil {
+        lastErr = err
+    }
+
+    end := Frame{Type: "res.end", ID: id, Ok: lastErr == nil}
+    if lastErr != nil {
+        end.Error = &ErrPayload{Code: "bridge_error", Message: lastErr.Error()}
+    }
+    safeWriteJSON(cs.ws, cs.writeMu, end)
 }
 
 func sendError(ws *websocket.Conn, writeMu *sync.Mutex, id, msg string) {
+    sendErrorCode(ws, writeMu, id, "bridge_error", msg)
+}
+
+func sendErrorCode(ws *websocket.Conn, writeMu *sync.Mutex, id, code, msg string) {
     safeWriteJSON(ws, writeMu, Frame{
         Type: "res",
         ID:   id,
         Ok:   false,
         Error: &ErrPayload{
-            Code:    "bridge_error",
+            Code:    code,
             Message: msg,
         },
     })
@@ -0,0 +1,197 @@
+package main
+
+import (
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/base64"
+    "encoding/json"
+    "fmt"
+    "testing"
+    "time"
+)
+
+func signJWT(t *testing.T, secret string, exp int64) string {
+    t.Helper()
+    header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+    payload, err := json.Marshal(struct {
+        Exp int64 `json:"exp"`
+    }{Exp: exp})
+    if err != nil {
+        t.Fatalf("marshal claims: %v", err)
+    }
+    body := header + "." + base64.RawURLEncoding.EncodeToString(payload)
+    mac := hmac.New(sha256.New, []byte(secret))
+    mac.Write([]byte(body))
+    sig := base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+    return body + "." + sig
+}
+
+func TestVerifyJWT(t *testing.T) {
+    prev := authSecret
+    authSecret = "test-secret"
+    defer func() { authSecret = prev }()
+
+    valid := signJWT(t, authSecret, time.Now().Add(time.Hour).Unix())
+    if !verifyJWT(valid) {
+        t.Error("expected a correctly signed, unexpired token to verify")
+    }
+
+    expired := signJWT(t, authSecret, time.Now().Add(-time.Hour).Unix())
+    if verifyJWT(expired) {
+        t.Error("expected an expired token to be rejected")
+    }
+
+    wrongKey := signJWT(t, "other-secret", time.Now().Add(time.Hour).Unix())
+    if verifyJWT(wrongKey) {
+        t.Error("expected a token signed with the wrong secret to be rejected")
+    }
+
+    if verifyJWT("not-a-jwt") {
+        t.Error("expected a malformed token to be rejected")
+    }
+}
+
+func TestVerifyConnectProofJWTIsReauthable(t *testing.T) {
+    prev := authSecret
+    authSecret = "test-secret"
+    defer func() { authSecret = prev }()
+
+    token := signJWT(t, authSecret, time.Now().Add(time.Hour).Unix())
+    params, err := json.Marshal(struct {
+        Token string `json:"token"`
+    }{Token: token})
+    if err != nil {
+        t.Fatalf("marshal params: %v", err)
+    }
+
+    credential, reauthable, ok := verifyConnectProof(1, params)
+    if !ok {
+        t.Fatal("expected a valid JWT proof to authenticate")
+    }
+    if credential != token {
+        t.Errorf("expected credential to be the bearer token, got %q", credential)
+    }
+    if !reauthable {
+        t.Error("expected a JWT credential to be reauthable")
+    }
+}
+
+func TestVerifyConnectProofHMACIsNotReauthable(t *testing.T) {
+    prev := authSecret
+    authSecret = "test-secret"
+    defer func() { authSecret = prev }()
+
+    nonce := int64(12345)
+    ts := time.Now().Unix()
+    mac := hmac.New(sha256.New, []byte(authSecret))
+    fmt.Fprintf(mac, "%d:%d", nonce, ts)
+    proof := fmt.Sprintf("%x", mac.Sum(nil))
+
+    params, err := json.Marshal(struct {
+        Proof string `json:"proof"`
+        Ts    int64  `json:"ts"`
+    }{Proof: proof, Ts: ts})
+    if err != nil {
+        t.Fatalf("marshal params: %v", err)
+    }
+
+    credential, reauthable, ok := verifyConnectProof(nonce, params)
+    if !ok {
+        t.Fatal("expected a valid HMAC proof to authenticate")
+    }
+    if credential != proof {
+        t.Errorf("expected credential to be the HMAC digest, got %q", credential)
+    }
+    if reauthable {
+        t.Error("expected an HMAC proof credential to not be reauthable, since it's only meaningful within its skew window")
+    }
+
+    // A proof computed over the wrong nonce must be rejected outright.
+    if _, _, ok := verifyConnectProof(nonce+1, params); ok {
+        t.Error("expected a proof for a different nonce to be rejected")
+    }
+}
+
+func TestCircuitBreakerHalfOpenAdmitsOnlyOneProbe(t *testing.T) {
+    prevCooldown := pol.breakerCooldown
+    pol.breakerCooldown = time.Millisecond
+    defer func() { pol.breakerCooldown = prevCooldown }()
+
+    cb := &circuitBreaker{state: breakerOpen, openedAt: time.Now().Add(-time.Second)}
+
+    admitted := 0
+    for i := 0; i < 5; i++ {
+        if cb.allow() {
+            admitted++
+        }
+    }
+    if admitted != 1 {
+        t.Errorf("expected exactly one half-open probe to be admitted, got %d", admitted)
+    }
+
+    // Until the probe's outcome is recorded, the breaker keeps rejecting.
+    if cb.allow() {
+        t.Error("expected the breaker to keep rejecting while a half-open probe is outstanding")
+    }
+
+    cb.recordSuccess()
+    if !cb.allow() {
+        t.Error("expected the breaker to admit requests again after a successful probe")
+    }
+}
+
+func TestCircuitBreakerHalfOpenFailureReopens(t *testing.T) {
+    prevCooldown := pol.breakerCooldown
+    pol.breakerCooldown = time.Millisecond
+    defer func() { pol.breakerCooldown = prevCooldown }()
+
+    cb := &circuitBreaker{state: breakerOpen, openedAt: time.Now().Add(-time.Second)}
+    if !cb.allow() {
+        t.Fatal("expected the first caller after cooldown to be admitted as the probe")
+    }
+    cb.recordFailure()
+    if cb.state != breakerOpen {
+        t.Errorf("expected a failed probe to reopen the breaker, got state %q", cb.state)
+    }
+    if cb.allow() {
+        t.Error("expected the breaker to reject immediately after reopening")
+    }
+}
+
+func TestSessionSubscribePublish(t *testing.T) {
+    s := &Session{Key: "test-session"}
+
+    s.publish(Frame{Type: "event", Event: "before", Seq: 1})
+
+    ch, replay := s.subscribe(0)
+    if len(replay) != 1 || replay[0].Seq != 1 {
+        t.Fatalf("expected subscribe to replay the one buffered event, got %+v", replay)
+    }
+
+    s.publish(Frame{Type: "event", Event: "after", Seq: 2})
+
+    select {
+    case fr := <-ch:
+        if fr.Seq != 2 {
+            t.Errorf("expected live event with seq 2, got %+v", fr)
+        }
+    case <-time.After(time.Second):
+        t.Fatal("timed out waiting for published event on subscriber channel")
+    }
+
+    s.unsubscribe(ch)
+    if _, ok := <-ch; ok {
+        t.Error("expected the subscriber channel to be closed after unsubscribe")
+    }
+}
+
+func TestSessionSubscribeReplayRespectsSinceSeq(t *testing.T) {
+    s := &Session{Key: "test-session"}
+    s.publish(Frame{Type: "event", Event: "old", Seq: 1})
+    s.publish(Frame{Type: "event", Event: "new", Seq: 2})
+
+    _, replay := s.subscribe(1)
+    if len(replay) != 1 || replay[0].Seq != 2 {
+        t.Fatalf("expected replay to only include events after sinceSeq, got %+v", replay)
+    }
+}
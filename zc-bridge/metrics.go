@@ -0,0 +1,61 @@
+package main
+
+import (
+    "net/http"
+
+    "github.com/prometheus/client_golang/prometheus"
+    "github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var metricsRegistry = prometheus.NewRegistry()
+
+var (
+    wsConnections = prometheus.NewGauge(prometheus.GaugeOpts{
+        Name: "zcbridge_ws_connections",
+        Help: "Current number of open websocket connections.",
+    })
+    rpcRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+        Name: "zcbridge_rpc_requests_total",
+        Help: "Total RPC requests handled, labeled by method and outcome.",
+    }, []string{"method", "ok"})
+    rpcDurationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+        Name: "zcbridge_rpc_duration_seconds",
+        Help: "RPC handling latency in seconds, labeled by method.",
+    }, []string{"method"})
+    upstreamErrorsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+        Name: "zcbridge_upstream_errors_total",
+        Help: "Total errors forwarding requests to the ZeroClaw upstream.",
+    })
+    heartbeatMissesTotal = prometheus.NewCounter(prometheus.CounterOpts{
+        Name: "zcbridge_heartbeat_misses_total",
+        Help: "Total connections closed for missing a heartbeat pong.",
+    })
+    activeSessionsGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+        Name: "zcbridge_active_sessions",
+        Help: "Current number of tracked sessions.",
+    })
+)
+
+func init() {
+    metricsRegistry.MustRegister(
+        wsConnections,
+        rpcRequestsTotal,
+        rpcDurationSeconds,
+        upstreamErrorsTotal,
+        heartbeatMissesTotal,
+        activeSessionsGauge,
+    )
+}
+
+// startMetricsServer serves Prometheus metrics on a separate admin listener
+// so scraping never shares a port with the websocket upgrade handler.
+func startMetricsServer(addr string) {
+    mux := http.NewServeMux()
+    mux.Handle("/metrics", promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{}))
+    go func() {
+        logger.Info("metrics listening", "addr", addr)
+        if err := http.ListenAndServe(addr, mux); err != nil {
+            logger.Error("metrics server stopped", "error", err.Error())
+        }
+    }()
+}